@@ -9,6 +9,7 @@ import (
 
 	"github.com/luan78zao/live_stream_downloader/internal/config"
 	"github.com/luan78zao/live_stream_downloader/internal/downloader"
+	"github.com/luan78zao/live_stream_downloader/internal/downloader/aria2"
 	"github.com/luan78zao/live_stream_downloader/internal/handler"
 )
 
@@ -18,6 +19,13 @@ func main() {
 
 	flag.StringVar(&cfg.ServerAddr, "addr", cfg.ServerAddr, "服务器地址")
 	flag.StringVar(&cfg.DataDir, "data", cfg.DataDir, "数据目录")
+	flag.Int64Var(&cfg.MaxDownloadSpeed, "max-speed", cfg.MaxDownloadSpeed, "全局下载限速，单位字节/秒，0表示不限速")
+	flag.IntVar(&cfg.MaxTasks, "max-tasks", cfg.MaxTasks, "最大同时下载任务数，0表示不限制")
+	flag.StringVar(&cfg.Driver, "driver", cfg.Driver, "下载驱动：local 或 aria2")
+	flag.StringVar(&cfg.Aria2RPCURL, "aria2-rpc-url", cfg.Aria2RPCURL, "aria2 JSON-RPC 地址")
+	flag.StringVar(&cfg.Aria2Token, "aria2-token", cfg.Aria2Token, "aria2 的 rpc-secret")
+	flag.IntVar(&cfg.HLSRefreshIntervalSec, "hls-refresh-seconds", cfg.HLSRefreshIntervalSec, "HLS播放列表重新拉取间隔默认值，单位秒")
+	flag.IntVar(&cfg.RTMPTimeoutSec, "rtmp-timeout-seconds", cfg.RTMPTimeoutSec, "RTMP/RTSP连接超时默认值，单位秒，0表示不超时")
 	flag.Parse()
 
 	// 确保数据目录是绝对路径
@@ -32,14 +40,26 @@ func main() {
 		log.Fatalf("创建数据目录失败: %v", err)
 	}
 
-	// 创建下载器
-	downloader, err := downloader.New(cfg.DataDir)
-	if err != nil {
-		log.Fatalf("创建下载器失败: %v", err)
+	// 根据配置选择下载驱动：默认使用内置下载器，也可以把任务转交给外部aria2c
+	var fetcher downloader.Fetcher
+	switch cfg.Driver {
+	case config.DriverAria2:
+		fetcher = aria2.New(aria2.Config{
+			RPCURL:  cfg.Aria2RPCURL,
+			Token:   cfg.Aria2Token,
+			DataDir: cfg.DataDir,
+			Options: cfg.Aria2Options,
+		})
+	default:
+		localDownloader, err := downloader.New(cfg.DataDir, cfg)
+		if err != nil {
+			log.Fatalf("创建下载器失败: %v", err)
+		}
+		fetcher = localDownloader
 	}
 
 	// 创建HTTP处理器
-	handler, err := handler.New(downloader, "web/templates", cfg.DataDir)
+	handler, err := handler.New(fetcher, "web/templates", cfg.DataDir)
 	if err != nil {
 		log.Fatalf("创建HTTP处理器失败: %v", err)
 	}
@@ -1,15 +1,34 @@
 package config
 
+// Driver 可选值：DriverLocal 使用内置的HTTP/分片下载器，DriverAria2 把任务转交给外部aria2c
+const (
+	DriverLocal = "local"
+	DriverAria2 = "aria2"
+)
+
 // Config 应用配置
 type Config struct {
-	ServerAddr string // 服务器地址
-	DataDir    string // 数据目录
+	ServerAddr       string // 服务器地址
+	DataDir          string // 数据目录
+	MaxDownloadSpeed int64  // 全局下载限速，单位字节/秒，0 表示不限速
+	MaxTasks         int    // 最大同时下载任务数，0 表示不限制
+
+	Driver       string         // 下载驱动：DriverLocal 或 DriverAria2，默认为 DriverLocal
+	Aria2RPCURL  string         // aria2 JSON-RPC 地址，例如 http://127.0.0.1:6800/jsonrpc
+	Aria2Token   string         // aria2 的 rpc-secret
+	Aria2Options map[string]any // 透传给 aria2.addUri/addTorrent 的额外选项
+
+	HLSRefreshIntervalSec int // HLS播放列表重新拉取间隔默认值（秒），<=0时使用内置默认值，可被单个任务覆盖
+	RTMPTimeoutSec        int // RTMP/RTSP连接超时默认值（秒），<=0表示不超时，可被单个任务覆盖
 }
 
 // NewDefaultConfig 创建默认配置
 func NewDefaultConfig() *Config {
 	return &Config{
-		ServerAddr: ":8080",
-		DataDir:    "./data",
+		ServerAddr:       ":8080",
+		DataDir:          "./data",
+		MaxDownloadSpeed: 0,
+		MaxTasks:         0,
+		Driver:           DriverLocal,
 	}
 }
\ No newline at end of file
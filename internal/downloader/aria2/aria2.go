@@ -0,0 +1,260 @@
+// Package aria2 通过 JSON-RPC 把下载任务转交给外部 aria2c 进程执行，
+// 实现 downloader.Fetcher 接口，可以在磁力链接/种子等 aria2 更擅长的场景下替代内置下载器
+package aria2
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luan78zao/live_stream_downloader/internal/model"
+)
+
+// monitorInterval 是轮询 aria2.tellStatus 的间隔
+const monitorInterval = 3 * time.Second
+
+// Config 描述如何连接外部 aria2c 以及新建任务时使用的默认选项
+type Config struct {
+	RPCURL  string         // aria2 JSON-RPC 地址，例如 http://127.0.0.1:6800/jsonrpc
+	Token   string         // aria2 启动时配置的 rpc-secret
+	DataDir string         // 下载目标目录，对应 aria2 的 dir 选项
+	Options map[string]any // 透传给 aria2.addUri/addTorrent 的额外选项
+}
+
+// Driver 是基于 aria2 JSON-RPC 的 downloader.Fetcher 实现
+type Driver struct {
+	cfg    Config
+	client *http.Client
+
+	mu    sync.RWMutex
+	tasks map[string]*model.Task // 以 aria2 的 gid 作为任务ID
+}
+
+// New 创建一个新的 aria2 驱动
+func New(cfg Config) *Driver {
+	return &Driver{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		tasks:  make(map[string]*model.Task),
+	}
+}
+
+// Create 调用 aria2.addUri（或 aria2.addTorrent）新建任务，随后启动一个 Monitor 协程跟踪进度。
+// url 可以是 HTTP/FTP 直链、magnet: 磁力链接，或者以 .torrent 结尾的服务器本地种子文件路径
+// （此时会读取文件内容并转换成 addTorrent 需要的base64编码）。hlsRefreshSeconds/rtmpTimeoutSeconds
+// 是本地驱动HLS/RTMP录制专用的参数，aria2本身不支持这两种协议，故此处忽略，仅为满足
+// downloader.Fetcher 接口
+func (d *Driver) Create(url, fileName string, hlsRefreshSeconds, rtmpTimeoutSeconds int) (*model.Task, error) {
+	// aria2的gid要等addUri/addTorrent调用返回后才知道，因此这里和
+	// Downloader.CreateTask一样先用时间戳兜底生成文件名，避免FilePath落到裸的数据目录上
+	if fileName == "" {
+		fileName = fmt.Sprintf("stream_%d.flv", time.Now().UnixNano())
+	}
+
+	options := map[string]any{"dir": d.cfg.DataDir, "out": fileName}
+	for k, v := range d.cfg.Options {
+		options[k] = v
+	}
+
+	method := "aria2.addUri"
+	params := append(d.authParams(), []string{url}, options)
+	if strings.HasSuffix(strings.ToLower(url), ".torrent") {
+		// aria2.addTorrent 的第一个参数是 base64 编码的种子文件内容，不是URI；
+		// 这里把url当作服务器本地可读的种子文件路径，读出内容后自己完成编码，
+		// 调用方（API的url字段）只需要传一个.torrent文件路径即可，不用关心编码细节
+		torrentData, readErr := os.ReadFile(url)
+		if readErr != nil {
+			return nil, fmt.Errorf("读取种子文件失败: %w", readErr)
+		}
+		method = "aria2.addTorrent"
+		params = append(d.authParams(), base64.StdEncoding.EncodeToString(torrentData), []string{}, options)
+	}
+
+	result, err := d.call(method, params)
+	if err != nil {
+		return nil, fmt.Errorf("新建aria2任务失败: %w", err)
+	}
+
+	var gid string
+	if err := json.Unmarshal(result, &gid); err != nil {
+		return nil, fmt.Errorf("解析aria2任务ID失败: %w", err)
+	}
+
+	task := &model.Task{
+		ID:        gid,
+		URL:       url,
+		FileName:  fileName,
+		FilePath:  filepath.Join(d.cfg.DataDir, fileName),
+		Status:    model.TaskStatusDownloading,
+		Resumable: true,
+		StartTime: time.Now(),
+	}
+
+	d.mu.Lock()
+	d.tasks[gid] = task
+	d.mu.Unlock()
+
+	go d.monitor(gid)
+
+	return task, nil
+}
+
+// Stop 调用 aria2.remove 取消一个正在下载的任务
+func (d *Driver) Stop(taskID string) error {
+	if _, err := d.call("aria2.remove", append(d.authParams(), taskID)); err != nil {
+		return fmt.Errorf("停止aria2任务失败: %w", err)
+	}
+	return nil
+}
+
+// Pause 调用 aria2.pause 暂停一个任务
+func (d *Driver) Pause(taskID string) error {
+	if _, err := d.call("aria2.pause", append(d.authParams(), taskID)); err != nil {
+		return fmt.Errorf("暂停aria2任务失败: %w", err)
+	}
+	return nil
+}
+
+// Resume 调用 aria2.unpause 恢复一个任务，并重新启动监控协程
+func (d *Driver) Resume(taskID string) error {
+	if _, err := d.call("aria2.unpause", append(d.authParams(), taskID)); err != nil {
+		return fmt.Errorf("恢复aria2任务失败: %w", err)
+	}
+	go d.monitor(taskID)
+	return nil
+}
+
+// Delete 调用 aria2.removeDownloadResult 清理任务记录，并从本地缓存中移除；
+// 磁盘上已下载的文件不会被删除
+func (d *Driver) Delete(taskID string) error {
+	if _, err := d.call("aria2.removeDownloadResult", append(d.authParams(), taskID)); err != nil {
+		return fmt.Errorf("删除aria2任务失败: %w", err)
+	}
+
+	d.mu.Lock()
+	delete(d.tasks, taskID)
+	d.mu.Unlock()
+
+	return nil
+}
+
+// Status 返回本地缓存的任务最新状态。返回快照拷贝而非内部指针，避免调用方在锁外
+// 读取时跟 monitor 协程对同一个task指针的并发写入产生数据竞争
+func (d *Driver) Status(taskID string) (*model.Task, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	task, exists := d.tasks[taskID]
+	if !exists {
+		return nil, false
+	}
+	cp := *task
+	return &cp, true
+}
+
+// ListActive 列出所有未完成的任务，返回快照拷贝，原因同 Status
+func (d *Driver) ListActive() []*model.Task {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	tasks := make([]*model.Task, 0, len(d.tasks))
+	for _, task := range d.tasks {
+		if task.Status != model.TaskStatusCompleted && task.Status != model.TaskStatusError {
+			cp := *task
+			tasks = append(tasks, &cp)
+		}
+	}
+	return tasks
+}
+
+// ListCompleted 列出所有已完成（含出错）的任务，返回快照拷贝，原因同 Status
+func (d *Driver) ListCompleted() []*model.Task {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	tasks := make([]*model.Task, 0, len(d.tasks))
+	for _, task := range d.tasks {
+		if task.Status == model.TaskStatusCompleted || task.Status == model.TaskStatusError {
+			cp := *task
+			tasks = append(tasks, &cp)
+		}
+	}
+	return tasks
+}
+
+// monitor 周期性调用 aria2.tellStatus 更新任务进度，直到任务完成、出错或被移除
+func (d *Driver) monitor(gid string) {
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.mu.RLock()
+		task, exists := d.tasks[gid]
+		d.mu.RUnlock()
+		if !exists {
+			return
+		}
+
+		status, err := d.tellStatus(gid)
+		if err != nil {
+			continue
+		}
+
+		d.mu.Lock()
+		task.TotalSize = int64(status.TotalLength)
+		task.DownloadedSize = int64(status.CompletedLength)
+		task.FileSize = int64(status.CompletedLength)
+		if speed, err := strconv.ParseFloat(status.DownloadSpeed, 64); err == nil {
+			task.Speed = speed
+		}
+
+		switch status.Status {
+		case "complete":
+			task.Status = model.TaskStatusCompleted
+			endTime := time.Now()
+			task.EndTime = &endTime
+			applyFiles(task, status.Files)
+		case "error":
+			task.Status = model.TaskStatusError
+			task.ErrorMessage = status.ErrorMessage
+		case "paused":
+			task.Status = model.TaskStatusPaused
+		case "removed":
+			task.Status = model.TaskStatusError
+			task.ErrorMessage = "任务已在aria2中被移除"
+		default:
+			task.Status = model.TaskStatusDownloading
+		}
+		done := task.Status == model.TaskStatusCompleted || task.Status == model.TaskStatusError
+		d.mu.Unlock()
+
+		if done {
+			return
+		}
+	}
+}
+
+// applyFiles 记录任务包含的文件列表；种子任务可能包含多个文件
+func applyFiles(task *model.Task, files []aria2File) {
+	if len(files) == 0 {
+		return
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	task.Files = paths
+
+	if len(paths) == 1 {
+		task.FilePath = paths[0]
+		task.FileName = filepath.Base(paths[0])
+	}
+}
@@ -0,0 +1,130 @@
+package aria2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// rpcRequest 是aria2 JSON-RPC 2.0 请求体
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      string `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+// rpcResponse 是aria2 JSON-RPC 2.0 响应体
+type rpcResponse struct {
+	ID     string          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// rpcError 对应aria2返回的错误信息
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// aria2File 是aria2.tellStatus返回的单个文件信息
+type aria2File struct {
+	Path string `json:"path"`
+}
+
+// aria2Status 是aria2.tellStatus返回结果中本驱动关心的字段；
+// aria2把数值都编码成了字符串，因此用 numericString 承接
+type aria2Status struct {
+	GID             string        `json:"gid"`
+	Status          string        `json:"status"`
+	TotalLength     numericString `json:"totalLength"`
+	CompletedLength numericString `json:"completedLength"`
+	DownloadSpeed   string        `json:"downloadSpeed"`
+	ErrorMessage    string        `json:"errorMessage"`
+	Files           []aria2File   `json:"files"`
+}
+
+// numericString 把aria2返回的字符串形式的数字解析成int64
+type numericString int64
+
+func (n *numericString) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*n = 0
+		return nil
+	}
+
+	var v int64
+	if _, err := fmt.Sscanf(s, "%d", &v); err != nil {
+		return fmt.Errorf("解析数值字段失败: %w", err)
+	}
+	*n = numericString(v)
+	return nil
+}
+
+// authParams 如果配置了 rpc-secret，返回带 token 的参数前缀
+func (d *Driver) authParams() []any {
+	if d.cfg.Token == "" {
+		return nil
+	}
+	return []any{"token:" + d.cfg.Token}
+}
+
+// call 向aria2发起一次JSON-RPC调用
+func (d *Driver) call(method string, params []any) (json.RawMessage, error) {
+	reqBody := rpcRequest{
+		JSONRPC: "2.0",
+		ID:      fmt.Sprintf("%d", time.Now().UnixNano()),
+		Method:  method,
+		Params:  params,
+	}
+
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化RPC请求失败: %w", err)
+	}
+
+	resp, err := d.client.Post(d.cfg.RPCURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("发送RPC请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取RPC响应失败: %w", err)
+	}
+
+	var rpcResp rpcResponse
+	if err := json.Unmarshal(body, &rpcResp); err != nil {
+		return nil, fmt.Errorf("解析RPC响应失败: %w", err)
+	}
+	if rpcResp.Error != nil {
+		return nil, fmt.Errorf("aria2返回错误(%d): %s", rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+
+	return rpcResp.Result, nil
+}
+
+// tellStatus 调用 aria2.tellStatus 查询任务详情
+func (d *Driver) tellStatus(gid string) (*aria2Status, error) {
+	keys := []string{"gid", "status", "totalLength", "completedLength", "downloadSpeed", "errorMessage", "files"}
+	params := append(d.authParams(), gid, keys)
+
+	result, err := d.call("aria2.tellStatus", params)
+	if err != nil {
+		return nil, fmt.Errorf("查询aria2任务状态失败: %w", err)
+	}
+
+	var status aria2Status
+	if err := json.Unmarshal(result, &status); err != nil {
+		return nil, fmt.Errorf("解析aria2任务状态失败: %w", err)
+	}
+
+	return &status, nil
+}
@@ -0,0 +1,392 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/luan78zao/live_stream_downloader/internal/model"
+)
+
+// 分片下载的并发worker数量
+const chunkWorkers = 4
+
+// chunkState 描述单个分片的下载状态
+type chunkState struct {
+	Index     int   `json:"index"`
+	Start     int64 `json:"start"`
+	End       int64 `json:"end"` // 包含该字节
+	Completed bool  `json:"completed"`
+}
+
+// partsState 对应磁盘上的 <filename>.parts.json 边车文件，记录分片下载进度
+type partsState struct {
+	URL       string       `json:"url"`
+	TotalSize int64        `json:"total_size"`
+	Chunks    []chunkState `json:"chunks"`
+}
+
+// partsFilePath 返回任务对应的分片状态边车文件路径
+func partsFilePath(task *model.Task) string {
+	return task.FilePath + ".parts.json"
+}
+
+// pickChunkSize 根据文件总大小选择合适的分片大小
+func pickChunkSize(totalSize int64) int64 {
+	const (
+		mb = 1024 * 1024
+		kb = 1024
+	)
+	switch {
+	case totalSize <= 10*mb:
+		return 32 * kb
+	case totalSize <= 100*mb:
+		return 1 * mb
+	default:
+		return 10 * mb
+	}
+}
+
+// splitChunks 按照分片大小把 [0, totalSize) 切分成若干分片
+func splitChunks(totalSize, chunkSize int64) []chunkState {
+	if chunkSize <= 0 {
+		chunkSize = totalSize
+	}
+
+	chunks := make([]chunkState, 0, totalSize/chunkSize+1)
+	var start int64
+	index := 0
+	for start < totalSize {
+		end := start + chunkSize - 1
+		if end >= totalSize {
+			end = totalSize - 1
+		}
+		chunks = append(chunks, chunkState{Index: index, Start: start, End: end})
+		start = end + 1
+		index++
+	}
+	return chunks
+}
+
+// probeRangeSupport 探测服务器是否支持 Range 请求，返回文件总大小
+func probeRangeSupport(client *http.Client, url string) (totalSize int64, resumable bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("创建探测请求失败: %w", err)
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("发送探测请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusPartialContent {
+		// 服务器返回了 Content-Range: bytes 0-0/12345
+		contentRange := resp.Header.Get("Content-Range")
+		if idx := strings.LastIndex(contentRange, "/"); idx != -1 {
+			if size, convErr := strconv.ParseInt(contentRange[idx+1:], 10, 64); convErr == nil && size > 0 {
+				return size, true, nil
+			}
+		}
+		return 0, false, nil
+	}
+
+	// 服务器忽略了本次 Range 请求：如果仍声明 Accept-Ranges: bytes 并给出了长度，按支持分片处理；
+	// 否则视为不支持 Range，只能退回到流式下载
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("Accept-Ranges") == "bytes" && resp.ContentLength > 0 {
+		return resp.ContentLength, true, nil
+	}
+
+	return 0, false, nil
+}
+
+// savePartsState 把分片下载进度写入边车文件
+func savePartsState(task *model.Task, chunks []chunkState) error {
+	state := partsState{URL: task.URL, TotalSize: task.TotalSize, Chunks: chunks}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化分片状态失败: %w", err)
+	}
+	if err := os.WriteFile(partsFilePath(task), data, 0644); err != nil {
+		return fmt.Errorf("写入分片状态文件失败: %w", err)
+	}
+	return nil
+}
+
+// loadPartsState 从边车文件读取分片下载进度
+func loadPartsState(task *model.Task) (*partsState, error) {
+	data, err := os.ReadFile(partsFilePath(task))
+	if err != nil {
+		return nil, fmt.Errorf("读取分片状态文件失败: %w", err)
+	}
+	var state partsState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("解析分片状态文件失败: %w", err)
+	}
+	return &state, nil
+}
+
+// startChunkedDownload 使用多分片并行方式下载支持 Range 的文件。onStarted在
+// cancelFuncs注册完成后被调用一次，用于通知等待任务探测结束的调用方（例如PauseTask）；
+// 由ResumeTask直接发起的恢复下载不需要这个通知，可以传nil
+func (d *Downloader) startChunkedDownload(task *model.Task, chunks []chunkState, downloadedSize int64, onStarted func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d.mu.Lock()
+	d.cancelFuncs[task.ID] = cancel
+	client := &http.Client{Timeout: 0}
+	d.clients[task.ID] = client
+	d.mu.Unlock()
+
+	if onStarted != nil {
+		onStarted()
+	}
+
+	defer func() {
+		d.mu.Lock()
+		delete(d.cancelFuncs, task.ID)
+		delete(d.clients, task.ID)
+		d.mu.Unlock()
+	}()
+
+	file, err := os.OpenFile(task.FilePath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		d.handleDownloadError(task, fmt.Errorf("创建文件失败: %w", err))
+		return
+	}
+	defer file.Close()
+
+	// 下载真正开始前先写一次初始状态：如果任务在第一个分片完成之前就被暂停，
+	// ResumeTask 依然能读到边车文件，从头恢复这些分片，而不是恢复失败
+	_ = savePartsState(task, chunks)
+
+	var (
+		stateMu  sync.Mutex
+		total    = downloadedSize
+		paused   bool
+		firstErr error
+		jobs     = make(chan int, len(chunks))
+		wg       sync.WaitGroup
+	)
+
+	for i := range chunks {
+		if !chunks[i].Completed {
+			jobs <- i
+		}
+	}
+	close(jobs)
+
+	worker := func() {
+		defer wg.Done()
+		for idx := range jobs {
+			select {
+			case <-ctx.Done():
+				stateMu.Lock()
+				paused = true
+				stateMu.Unlock()
+				return
+			default:
+			}
+
+			chunk := chunks[idx]
+			n, err := d.downloadChunk(ctx, client, task, file, chunk)
+
+			stateMu.Lock()
+			if err != nil {
+				if ctx.Err() != nil {
+					paused = true
+				} else if firstErr == nil {
+					firstErr = err
+				}
+				stateMu.Unlock()
+				continue
+			}
+
+			chunks[idx].Completed = true
+			total += n
+			localTotal := total
+			stateMu.Unlock()
+
+			d.mu.Lock()
+			task.DownloadedSize = localTotal
+			task.FileSize = localTotal
+			d.mu.Unlock()
+			_ = savePartsState(task, chunks)
+			d.persistTask(task)
+		}
+	}
+
+	workerCount := chunkWorkers
+	if workerCount > len(chunks) {
+		workerCount = len(chunks)
+	}
+	if workerCount < 1 {
+		workerCount = 1
+	}
+	wg.Add(workerCount)
+	for i := 0; i < workerCount; i++ {
+		go worker()
+	}
+	wg.Wait()
+
+	if paused {
+		// 取消可能来自 PauseTask（任务还在 activeTasks 中，状态为 paused，需要我们
+		// 自己释放并发名额让排队任务得以继续），也可能来自 StopTask/DeleteActiveTask
+		// （它们已经释放过名额了，这里不能再释放一次，否则并发上限形同虚设）
+		d.mu.RLock()
+		_, stillActive := d.activeTasks[task.ID]
+		isPaused := stillActive && task.Status == model.TaskStatusPaused
+		d.mu.RUnlock()
+		if isPaused {
+			d.releaseSlot()
+		}
+		return
+	}
+	if firstErr != nil {
+		d.handleDownloadError(task, firstErr)
+		return
+	}
+
+	allDone := true
+	for _, c := range chunks {
+		if !c.Completed {
+			allDone = false
+			break
+		}
+	}
+	if allDone {
+		os.Remove(partsFilePath(task))
+		d.completeTask(task, total)
+	}
+}
+
+// downloadChunk 下载单个分片并写入目标文件的对应偏移
+func (d *Downloader) downloadChunk(ctx context.Context, client *http.Client, task *model.Task, file *os.File, chunk chunkState) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, task.URL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("创建分片请求失败: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", chunk.Start, chunk.End))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("下载分片失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("分片服务器返回错误状态码: %d", resp.StatusCode)
+	}
+
+	// 分片下载共用同一个全局令牌桶，整体聚合限速同样适用于分片下载
+	body := d.applyRateLimit(resp.Body)
+
+	buf := make([]byte, 32*1024)
+	offset := chunk.Start
+	var written int64
+	for {
+		select {
+		case <-ctx.Done():
+			return written, ctx.Err()
+		default:
+		}
+
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if _, writeErr := file.WriteAt(buf[:n], offset); writeErr != nil {
+				return written, fmt.Errorf("写入分片数据失败: %w", writeErr)
+			}
+			offset += int64(n)
+			written += int64(n)
+		}
+		if readErr != nil {
+			if readErr == io.EOF {
+				return written, nil
+			}
+			return written, fmt.Errorf("读取分片数据失败: %w", readErr)
+		}
+	}
+}
+
+// PauseTask 暂停一个支持断点续传的下载任务，保留已下载的分片进度
+func (d *Downloader) PauseTask(taskID string) error {
+	d.mu.Lock()
+	task, exists := d.activeTasks[taskID]
+	if !exists {
+		d.mu.Unlock()
+		return fmt.Errorf("任务不存在: %s", taskID)
+	}
+	probeDone, probing := d.probing[taskID]
+	d.mu.Unlock()
+
+	if probing {
+		// 任务刚创建，是否支持Range/分片还在探测中，Resumable尚未有最终结果；
+		// 等探测结束后再判断，避免把"还不知道"误判成"不支持断点续传"
+		<-probeDone
+	}
+
+	d.mu.Lock()
+	if !task.Resumable {
+		d.mu.Unlock()
+		return fmt.Errorf("任务不支持断点续传: %s", taskID)
+	}
+	cancel, hasCancel := d.cancelFuncs[taskID]
+	task.Status = model.TaskStatusPaused
+	d.mu.Unlock()
+
+	d.persistTask(task)
+
+	if hasCancel {
+		cancel()
+	}
+	return nil
+}
+
+// ResumeTask 从边车文件恢复未完成的分片，继续下载。暂停（paused）和进程重启后
+// 标记为中断（interrupted）的任务都可以通过该方法恢复
+func (d *Downloader) ResumeTask(taskID string) error {
+	d.mu.Lock()
+	task, exists := d.activeTasks[taskID]
+	if !exists {
+		d.mu.Unlock()
+		return fmt.Errorf("任务不存在: %s", taskID)
+	}
+	if task.Status != model.TaskStatusPaused && task.Status != model.TaskStatusInterrupted {
+		d.mu.Unlock()
+		return fmt.Errorf("任务不是暂停或中断状态: %s", taskID)
+	}
+	d.mu.Unlock()
+
+	if !d.acquireSlot() {
+		return fmt.Errorf("已达到最大并发下载任务数，请稍后重试: %s", taskID)
+	}
+
+	d.mu.Lock()
+	task.Status = model.TaskStatusDownloading
+	d.mu.Unlock()
+	d.persistTask(task)
+
+	state, err := loadPartsState(task)
+	if err != nil {
+		d.releaseSlot()
+		return fmt.Errorf("恢复任务失败: %w", err)
+	}
+
+	var downloaded int64
+	for _, c := range state.Chunks {
+		if c.Completed {
+			downloaded += c.End - c.Start + 1
+		}
+	}
+
+	go d.startChunkedDownload(task, state.Chunks, downloaded, nil)
+	return nil
+}
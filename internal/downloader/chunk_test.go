@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luan78zao/live_stream_downloader/internal/config"
+	"github.com/luan78zao/live_stream_downloader/internal/model"
+)
+
+// rangeServer 启动一个支持 Range 请求的HTTP服务器，每个分片请求之间停顿一小段时间，
+// 便于在分片下载尚未完成时调用 PauseTask
+func rangeServer(t *testing.T, data []byte) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader := r.Header.Get("Range")
+		if rangeHeader == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data)
+			return
+		}
+
+		var start, end int
+		if _, err := fmt.Sscanf(strings.TrimPrefix(rangeHeader, "bytes="), "%d-%d", &start, &end); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if end >= len(data) {
+			end = len(data) - 1
+		}
+
+		time.Sleep(80 * time.Millisecond)
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[start : end+1])
+	}))
+}
+
+// TestPauseResumeChunkedDownload 验证分片下载可以在中途暂停、保留已完成分片的进度，
+// 并在恢复后从断点继续完成，最终文件内容与源数据一致
+func TestPauseResumeChunkedDownload(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 4000) // 40000 字节，按32KB分片会切成2片
+
+	srv := rangeServer(t, data)
+	defer srv.Close()
+
+	d, err := New(t.TempDir(), &config.Config{})
+	if err != nil {
+		t.Fatalf("创建下载器失败: %v", err)
+	}
+
+	task, err := d.CreateTask(srv.URL, "file.bin", 0, 0)
+	if err != nil {
+		t.Fatalf("创建任务失败: %v", err)
+	}
+
+	// 等待分片下载开始，但在80ms的模拟延迟内完成之前暂停
+	time.Sleep(20 * time.Millisecond)
+	if err := d.PauseTask(task.ID); err != nil {
+		t.Fatalf("暂停任务失败: %v", err)
+	}
+
+	// 等待被取消的worker退出
+	time.Sleep(150 * time.Millisecond)
+
+	paused, exists := d.GetTask(task.ID)
+	if !exists || paused.Status != model.TaskStatusPaused {
+		t.Fatalf("期望任务处于paused状态，实际: %+v", paused)
+	}
+	if _, err := os.Stat(partsFilePath(task)); err != nil {
+		t.Fatalf("期望分片进度文件存在: %v", err)
+	}
+
+	if err := d.ResumeTask(task.ID); err != nil {
+		t.Fatalf("恢复任务失败: %v", err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if completed, exists := d.GetTask(task.ID); exists && completed.Status == model.TaskStatusCompleted {
+			got, err := os.ReadFile(task.FilePath)
+			if err != nil {
+				t.Fatalf("读取下载文件失败: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("恢复下载后的文件内容与源数据不一致")
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("恢复后的下载任务未在超时时间内完成")
+}
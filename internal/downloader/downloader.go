@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"os"
@@ -11,7 +12,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/juju/ratelimit"
+
+	"github.com/luan78zao/live_stream_downloader/internal/config"
 	"github.com/luan78zao/live_stream_downloader/internal/model"
+	"github.com/luan78zao/live_stream_downloader/internal/store"
 )
 
 // Downloader 管理直播流下载任务
@@ -19,29 +24,127 @@ type Downloader struct {
 	mu             sync.RWMutex
 	activeTasks    map[string]*model.Task
 	completedTasks map[string]*model.Task
+	readyQueue     []*model.Task
 	dataDir        string
 	clients        map[string]*http.Client
 	cancelFuncs    map[string]context.CancelFunc
+	probing        map[string]chan struct{} // 任务ID -> 探测是否支持Range/分片完成的信号，PauseTask据此等待Resumable被最终确定
+	store          *store.Store
+
+	limitMu      sync.RWMutex
+	bucket       *ratelimit.Bucket
+	maxTasks     int
+	runningTasks int
+
+	subsMu      sync.Mutex
+	subscribers map[chan Event]struct{}
+	samplesMu   sync.Mutex
+	samples     map[string]*ringBuffer
+
+	hlsRefreshDefault  time.Duration // HLS播放列表重新拉取间隔默认值，任务未覆盖时使用
+	rtmpTimeoutDefault time.Duration // RTMP/RTSP连接超时默认值，任务未覆盖时使用
 }
 
-// New 创建一个新的下载器实例
-func New(dataDir string) (*Downloader, error) {
+// New 创建一个新的下载器实例，并从 SQLite 中恢复上次未完成的任务
+func New(dataDir string, cfg *config.Config) (*Downloader, error) {
 	// 确保数据目录存在
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return nil, fmt.Errorf("创建数据目录失败: %w", err)
 	}
 
-	return &Downloader{
+	st, err := store.New(filepath.Join(dataDir, "tasks.db"))
+	if err != nil {
+		return nil, fmt.Errorf("初始化任务存储失败: %w", err)
+	}
+
+	d := &Downloader{
 		activeTasks:    make(map[string]*model.Task),
 		completedTasks: make(map[string]*model.Task),
 		dataDir:        dataDir,
 		clients:        make(map[string]*http.Client),
 		cancelFuncs:    make(map[string]context.CancelFunc),
-	}, nil
+		probing:        make(map[string]chan struct{}),
+		store:          st,
+		subscribers:    make(map[chan Event]struct{}),
+		samples:        make(map[string]*ringBuffer),
+	}
+
+	if cfg != nil {
+		d.SetLimits(cfg.MaxDownloadSpeed, cfg.MaxTasks)
+		if cfg.HLSRefreshIntervalSec > 0 {
+			d.hlsRefreshDefault = time.Duration(cfg.HLSRefreshIntervalSec) * time.Second
+		}
+		if cfg.RTMPTimeoutSec > 0 {
+			d.rtmpTimeoutDefault = time.Duration(cfg.RTMPTimeoutSec) * time.Second
+		}
+	}
+
+	if err := d.loadPersistedTasks(); err != nil {
+		return nil, err
+	}
+
+	go d.runProgressLoop()
+
+	return d, nil
+}
+
+// persistTask 把任务的最新状态写入数据库；持久化失败不影响内存中的下载流程，只记录日志。
+// 先在锁内拷贝一份快照再传给 store，避免 gorm 在锁外反射读取/写入 task 字段时
+// 跟后台goroutine对同一个task指针的并发写入产生数据竞争；save之后把gorm补写的
+// CreatedAt/UpdatedAt同步回活动任务，保证内存中看到的任务信息与落库结果一致
+func (d *Downloader) persistTask(task *model.Task) {
+	d.mu.RLock()
+	snapshot := *task
+	d.mu.RUnlock()
+
+	if err := d.store.SaveTask(&snapshot); err != nil {
+		log.Printf("持久化任务 %s 失败: %v", task.ID, err)
+		return
+	}
+
+	d.mu.Lock()
+	task.CreatedAt = snapshot.CreatedAt
+	task.UpdatedAt = snapshot.UpdatedAt
+	d.mu.Unlock()
 }
 
-// CreateTask 创建一个新的下载任务
-func (d *Downloader) CreateTask(url, fileName string) (*model.Task, error) {
+// loadPersistedTasks 在启动时恢复上次未完成的任务：下载中的任务标记为已中断，
+// 暂停和排队中的任务保持原状态，之后尝试把排队任务重新派发出去
+func (d *Downloader) loadPersistedTasks() error {
+	tasks, err := d.store.GetTasksByStatus(
+		model.TaskStatusDownloading,
+		model.TaskStatusInterrupted,
+		model.TaskStatusPaused,
+		model.TaskStatusReady,
+	)
+	if err != nil {
+		return fmt.Errorf("加载未完成任务失败: %w", err)
+	}
+
+	d.mu.Lock()
+	for _, task := range tasks {
+		if task.Status == model.TaskStatusDownloading {
+			task.Status = model.TaskStatusInterrupted
+		}
+		d.activeTasks[task.ID] = task
+		if task.Status == model.TaskStatusReady {
+			d.readyQueue = append(d.readyQueue, task)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, task := range tasks {
+		d.persistTask(task)
+	}
+
+	d.dispatchReady()
+	return nil
+}
+
+// CreateTask 创建一个新的下载任务。hlsRefreshSeconds/rtmpTimeoutSeconds
+// 分别是HLS播放列表重新拉取间隔、RTMP/RTSP连接超时的按任务覆盖值，<=0表示使用
+// 各自的默认值；它们只在url被识别为对应协议时才生效
+func (d *Downloader) CreateTask(url, fileName string, hlsRefreshSeconds, rtmpTimeoutSeconds int) (*model.Task, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
@@ -55,28 +158,95 @@ func (d *Downloader) CreateTask(url, fileName string) (*model.Task, error) {
 		fileName = fileName + ".flv"
 	}
 
+	// 根据URL的scheme/扩展名选择拉流协议：m3u8走HLS录制，rtmp/rtsp走RTMP拉流，
+	// 其余情况回退到原有的HTTP(S)下载（支持Range时自动使用分片并行）
+	protocol := detectProtocol(url)
+
 	// 创建任务
 	task := &model.Task{
-		ID:        taskID,
-		URL:       url,
-		FileName:  fileName,
-		FilePath:  filepath.Join(d.dataDir, fileName),
-		Status:    model.TaskStatusDownloading,
-		FileSize:  0,
-		StartTime: time.Now(),
+		ID:                 taskID,
+		URL:                url,
+		FileName:           fileName,
+		FilePath:           filepath.Join(d.dataDir, fileName),
+		Status:             model.TaskStatusDownloading,
+		FileSize:           0,
+		StartTime:          time.Now(),
+		Protocol:           protocol,
+		HLSRefreshSeconds:  hlsRefreshSeconds,
+		RTMPTimeoutSeconds: rtmpTimeoutSeconds,
 	}
 
 	// 保存任务
 	d.activeTasks[taskID] = task
 
-	// 启动下载
-	go d.startDownload(task)
+	// 如果已经达到最大并发任务数，任务进入排队状态，等待有任务结束后再开始下载
+	if d.acquireSlot() {
+		d.dispatchTask(task)
+	} else {
+		task.Status = model.TaskStatusReady
+		d.readyQueue = append(d.readyQueue, task)
+	}
+
+	// persistTask 内部会再次获取 d.mu，这里先临时释放写锁，避免同一goroutine重复加锁死锁
+	d.mu.Unlock()
+	d.persistTask(task)
+	d.mu.Lock()
 
 	return task, nil
 }
 
-// startDownload 开始下载任务
-func (d *Downloader) startDownload(task *model.Task) {
+// dispatchTask 登记任务进入"探测/下载中"状态并启动后台goroutine执行；调用方必须持有 d.mu
+func (d *Downloader) dispatchTask(task *model.Task) {
+	d.probing[task.ID] = make(chan struct{})
+	go d.beginDownload(task)
+}
+
+// finishProbing 关闭并清理任务的探测完成信号，唤醒等待在其上的 PauseTask
+func (d *Downloader) finishProbing(taskID string) {
+	d.mu.Lock()
+	if ch, exists := d.probing[taskID]; exists {
+		close(ch)
+		delete(d.probing, taskID)
+	}
+	d.mu.Unlock()
+}
+
+// beginDownload 根据任务的Protocol分派到对应的下载逻辑；HTTP协议额外探测是否
+// 支持分片下载。探测/协议判定结束后会调用 finishProbing 通知等待中的 PauseTask，
+// 这样调用方在 CreateTask 刚返回、探测请求还未完成时调用 Pause 也不会被误判为
+// "不支持断点续传"
+func (d *Downloader) beginDownload(task *model.Task) {
+	switch task.Protocol {
+	case model.ProtocolHLS:
+		d.finishProbing(task.ID)
+		d.startHLSDownload(task)
+		return
+	case model.ProtocolRTMP:
+		d.finishProbing(task.ID)
+		d.startRTMPDownload(task)
+		return
+	}
+
+	// 探测服务器是否支持 Range 分片下载（例如点播视频），支持则使用多分片并行下载
+	// 并可断点续传；不支持（例如直播流）则退回到原来的单连接流式下载
+	probeClient := &http.Client{Timeout: 10 * time.Second}
+	if totalSize, resumable, probeErr := probeRangeSupport(probeClient, task.URL); probeErr == nil && resumable {
+		d.mu.Lock()
+		task.TotalSize = totalSize
+		task.Resumable = true
+		d.mu.Unlock()
+
+		chunks := splitChunks(totalSize, pickChunkSize(totalSize))
+		// onStarted在cancelFuncs注册完成后才回调，确保PauseTask被唤醒时一定能找到对应的取消函数
+		d.startChunkedDownload(task, chunks, 0, func() { d.finishProbing(task.ID) })
+	} else {
+		d.startDownload(task, func() { d.finishProbing(task.ID) })
+	}
+}
+
+// startDownload 开始下载任务。onStarted在cancelFuncs注册完成后被调用一次，
+// 用于通知等待任务探测结束的调用方（例如PauseTask）
+func (d *Downloader) startDownload(task *model.Task, onStarted func()) {
 	// 创建上下文，以便能够取消下载
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -103,6 +273,10 @@ func (d *Downloader) startDownload(task *model.Task) {
 	d.clients[task.ID] = client
 	d.mu.Unlock()
 
+	if onStarted != nil {
+		onStarted()
+	}
+
 	// 确保在函数退出时清理资源
 	defer func() {
 		d.mu.Lock()
@@ -140,13 +314,16 @@ func (d *Downloader) startDownload(task *model.Task) {
 	}
 	defer file.Close()
 
+	// 如果设置了全局限速，用限速 Reader 包一层，读取速度将被限制在令牌桶的速率之内
+	body := d.applyRateLimit(resp.Body)
+
 	// 设置缓冲区大小
 	bufSize := 32 * 1024 // 32KB
 	buf := make([]byte, bufSize)
 
 	// 开始下载
 	var totalBytes int64
-	updateInterval := time.Second * 1 // 每秒更新一次状态
+	updateInterval := progressTickInterval // 与SSE进度推送频率保持一致
 	lastUpdate := time.Now()
 
 	for {
@@ -160,7 +337,7 @@ func (d *Downloader) startDownload(task *model.Task) {
 		}
 
 		// 读取数据
-		n, err := resp.Body.Read(buf)
+		n, err := body.Read(buf)
 		if n > 0 {
 			// 写入文件
 			if _, writeErr := file.Write(buf[:n]); writeErr != nil {
@@ -185,6 +362,10 @@ func (d *Downloader) startDownload(task *model.Task) {
 			if err == io.EOF {
 				// 正常结束
 				d.completeTask(task, totalBytes)
+			} else if ctx.Err() != nil {
+				// 下载是被 StopTask/DeleteActiveTask 取消的，它们已经更新了任务状态
+				// 并释放了并发名额，这里直接返回，避免重复释放
+				return
 			} else {
 				// 发生错误
 				d.handleDownloadError(task, fmt.Errorf("读取数据失败: %w", err))
@@ -197,7 +378,6 @@ func (d *Downloader) startDownload(task *model.Task) {
 // handleDownloadError 处理下载错误
 func (d *Downloader) handleDownloadError(task *model.Task, err error) {
 	d.mu.Lock()
-	defer d.mu.Unlock()
 
 	// 更新任务状态
 	task.Status = model.TaskStatusError
@@ -208,12 +388,15 @@ func (d *Downloader) handleDownloadError(task *model.Task, err error) {
 	// 将任务从活动任务移动到已完成任务
 	delete(d.activeTasks, task.ID)
 	d.completedTasks[task.ID] = task
+	d.mu.Unlock()
+
+	d.persistTask(task)
+	d.releaseSlot()
 }
 
 // completeTask 完成下载任务
 func (d *Downloader) completeTask(task *model.Task, totalBytes int64) {
 	d.mu.Lock()
-	defer d.mu.Unlock()
 
 	// 更新任务状态
 	task.Status = model.TaskStatusCompleted
@@ -224,19 +407,26 @@ func (d *Downloader) completeTask(task *model.Task, totalBytes int64) {
 	// 将任务从活动任务移动到已完成任务
 	delete(d.activeTasks, task.ID)
 	d.completedTasks[task.ID] = task
+	d.mu.Unlock()
+
+	d.persistTask(task)
+	d.releaseSlot()
 }
 
 // StopTask 停止下载任务
 func (d *Downloader) StopTask(taskID string) error {
 	d.mu.Lock()
-	defer d.mu.Unlock()
 
 	// 检查任务是否存在
 	task, exists := d.activeTasks[taskID]
 	if !exists {
+		d.mu.Unlock()
 		return fmt.Errorf("任务不存在: %s", taskID)
 	}
 
+	wasRunning := task.Status == model.TaskStatusDownloading
+	wasReady := task.Status == model.TaskStatusReady
+
 	// 取消下载
 	if cancel, exists := d.cancelFuncs[taskID]; exists {
 		cancel()
@@ -251,48 +441,64 @@ func (d *Downloader) StopTask(taskID string) error {
 	delete(d.activeTasks, taskID)
 	d.completedTasks[taskID] = task
 
+	if wasReady {
+		d.removeFromReadyQueue(taskID)
+	}
+	d.mu.Unlock()
+
+	d.persistTask(task)
+	if wasRunning {
+		d.releaseSlot()
+	}
+
 	return nil
 }
 
-// GetActiveTasks 获取所有活动任务
+// GetActiveTasks 获取所有活动任务。返回的是快照拷贝而非内部指针，调用方（如HTTP
+// handler的JSON编码）可能在锁外长时间持有并读取这些任务，拷贝可以避免跟后台下载
+// goroutine对同一个task指针的并发读写产生数据竞争
 func (d *Downloader) GetActiveTasks() []*model.Task {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
 	tasks := make([]*model.Task, 0, len(d.activeTasks))
 	for _, task := range d.activeTasks {
-		tasks = append(tasks, task)
+		cp := *task
+		tasks = append(tasks, &cp)
 	}
 
 	return tasks
 }
 
-// GetCompletedTasks 获取所有已完成任务
+// GetCompletedTasks 获取所有已完成任务，返回快照拷贝，原因同 GetActiveTasks
 func (d *Downloader) GetCompletedTasks() []*model.Task {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
 	tasks := make([]*model.Task, 0, len(d.completedTasks))
 	for _, task := range d.completedTasks {
-		tasks = append(tasks, task)
+		cp := *task
+		tasks = append(tasks, &cp)
 	}
 
 	return tasks
 }
 
-// GetTask 获取指定任务
+// GetTask 获取指定任务，返回快照拷贝，原因同 GetActiveTasks
 func (d *Downloader) GetTask(taskID string) (*model.Task, bool) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
 	// 先检查活动任务
 	if task, exists := d.activeTasks[taskID]; exists {
-		return task, true
+		cp := *task
+		return &cp, true
 	}
 
 	// 再检查已完成任务
 	if task, exists := d.completedTasks[taskID]; exists {
-		return task, true
+		cp := *task
+		return &cp, true
 	}
 
 	return nil, false
@@ -301,14 +507,17 @@ func (d *Downloader) GetTask(taskID string) (*model.Task, bool) {
 // DeleteActiveTask 删除正在下载的任务
 func (d *Downloader) DeleteActiveTask(taskID string) error {
 	d.mu.Lock()
-	defer d.mu.Unlock()
 
 	// 检查任务是否存在
 	task, exists := d.activeTasks[taskID]
 	if !exists {
+		d.mu.Unlock()
 		return fmt.Errorf("任务不存在: %s", taskID)
 	}
 
+	wasRunning := task.Status == model.TaskStatusDownloading
+	wasReady := task.Status == model.TaskStatusReady
+
 	// 取消下载
 	if cancel, exists := d.cancelFuncs[taskID]; exists {
 		cancel()
@@ -316,11 +525,27 @@ func (d *Downloader) DeleteActiveTask(taskID string) error {
 
 	// 删除文件
 	if err := os.Remove(task.FilePath); err != nil && !os.IsNotExist(err) {
+		d.mu.Unlock()
 		return fmt.Errorf("删除文件失败: %w", err)
 	}
+	// 删除分片状态边车文件（如果存在）
+	os.Remove(partsFilePath(task))
 
 	// 从活动任务中删除
 	delete(d.activeTasks, taskID)
+	if wasReady {
+		d.removeFromReadyQueue(taskID)
+	}
+	d.mu.Unlock()
+
+	// 级联删除数据库中的任务记录
+	if err := d.store.DeleteTask(taskID); err != nil {
+		log.Printf("删除任务 %s 的数据库记录失败: %v", taskID, err)
+	}
+
+	if wasRunning {
+		d.releaseSlot()
+	}
 
 	return nil
 }
@@ -340,9 +565,17 @@ func (d *Downloader) DeleteCompletedTask(taskID string) error {
 	if err := os.Remove(task.FilePath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("删除文件失败: %w", err)
 	}
+	// 删除分片状态边车文件（如果存在）：分片下载出错时会直接进入completedTasks，
+	// 此时边车文件还没来得及被清理，需要在这里一并删除，否则会永久遗留
+	os.Remove(partsFilePath(task))
 
 	// 从已完成任务中删除
 	delete(d.completedTasks, taskID)
 
+	// 级联删除数据库中的任务记录
+	if err := d.store.DeleteTask(taskID); err != nil {
+		log.Printf("删除任务 %s 的数据库记录失败: %v", taskID, err)
+	}
+
 	return nil
 }
@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luan78zao/live_stream_downloader/internal/config"
+	"github.com/luan78zao/live_stream_downloader/internal/model"
+)
+
+// slowServer 启动一个不支持 Range 的HTTP服务器，持续小块写入数据并在两次写入之间
+// 停顿，模拟一个长时间处于下载中状态的直播流，便于在任务仍然"downloading"时调用StopTask
+func slowServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, _ := w.(http.Flusher)
+		for i := 0; i < 50; i++ {
+			if _, err := w.Write([]byte("x")); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+}
+
+// TestStopTaskReleasesSlotExactlyOnce 验证停止一个正在下载的任务只释放一个并发名额：
+// 被取消的后台goroutine读到的是context取消导致的错误（不是io.EOF），不应该再次释放
+// StopTask已经释放过的名额，否则并发上限会被绕过（排队任务被连续派发两个而不是一个）
+func TestStopTaskReleasesSlotExactlyOnce(t *testing.T) {
+	srv := slowServer(t)
+	defer srv.Close()
+
+	d, err := New(t.TempDir(), &config.Config{MaxTasks: 2})
+	if err != nil {
+		t.Fatalf("创建下载器失败: %v", err)
+	}
+
+	task1, err := d.CreateTask(srv.URL, "a.flv", 0, 0)
+	if err != nil {
+		t.Fatalf("创建任务1失败: %v", err)
+	}
+	if _, err := d.CreateTask(srv.URL, "b.flv", 0, 0); err != nil {
+		t.Fatalf("创建任务2失败: %v", err)
+	}
+	queued1, err := d.CreateTask(srv.URL, "c.flv", 0, 0)
+	if err != nil {
+		t.Fatalf("创建任务3失败: %v", err)
+	}
+	queued2, err := d.CreateTask(srv.URL, "d.flv", 0, 0)
+	if err != nil {
+		t.Fatalf("创建任务4失败: %v", err)
+	}
+
+	if queued1.Status != model.TaskStatusReady || queued2.Status != model.TaskStatusReady {
+		t.Fatalf("期望任务3和任务4进入排队状态，实际: %s, %s", queued1.Status, queued2.Status)
+	}
+
+	if err := d.StopTask(task1.ID); err != nil {
+		t.Fatalf("停止任务1失败: %v", err)
+	}
+
+	// 等待被取消的下载goroutine走完自己的退出路径
+	time.Sleep(200 * time.Millisecond)
+
+	downloading := 0
+	for _, task := range d.GetActiveTasks() {
+		if task.Status == model.TaskStatusDownloading {
+			downloading++
+		}
+	}
+	if downloading != 2 {
+		t.Fatalf("并发下载任务数期望为2（并发上限），实际为%d；并发名额被重复释放会绕过并发上限", downloading)
+	}
+}
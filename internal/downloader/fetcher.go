@@ -0,0 +1,79 @@
+package downloader
+
+import "github.com/luan78zao/live_stream_downloader/internal/model"
+
+// Fetcher 是下载驱动的统一接口。本地的 *Downloader 通过单个HTTP连接或分片并行
+// 直接拉取数据；internal/downloader/aria2 的驱动则把任务转交给外部aria2c执行。
+// Handler 面向该接口编程，从而可以在启动时选择具体驱动而无需改动上层代码
+type Fetcher interface {
+	// Create 创建一个新的下载任务。hlsRefreshSeconds/rtmpTimeoutSeconds是HLS/RTMP
+	// 协议专用的按任务覆盖参数，<=0表示使用驱动的默认值；不支持这两个协议的驱动可忽略
+	Create(url, fileName string, hlsRefreshSeconds, rtmpTimeoutSeconds int) (*model.Task, error)
+	// Stop 停止一个正在下载的任务
+	Stop(taskID string) error
+	// Pause 暂停一个支持断点续传的任务
+	Pause(taskID string) error
+	// Resume 恢复一个已暂停的任务
+	Resume(taskID string) error
+	// Delete 删除任务及其对应的文件
+	Delete(taskID string) error
+	// Status 查询任务当前状态
+	Status(taskID string) (*model.Task, bool)
+	// ListActive 列出所有正在进行（含排队、暂停）的任务
+	ListActive() []*model.Task
+	// ListCompleted 列出所有已完成（含出错）的任务
+	ListCompleted() []*model.Task
+}
+
+var _ Fetcher = (*Downloader)(nil)
+
+// Create 实现 Fetcher 接口，等价于 CreateTask
+func (d *Downloader) Create(url, fileName string, hlsRefreshSeconds, rtmpTimeoutSeconds int) (*model.Task, error) {
+	return d.CreateTask(url, fileName, hlsRefreshSeconds, rtmpTimeoutSeconds)
+}
+
+// Stop 实现 Fetcher 接口，等价于 StopTask
+func (d *Downloader) Stop(taskID string) error {
+	return d.StopTask(taskID)
+}
+
+// Pause 实现 Fetcher 接口，等价于 PauseTask
+func (d *Downloader) Pause(taskID string) error {
+	return d.PauseTask(taskID)
+}
+
+// Resume 实现 Fetcher 接口，等价于 ResumeTask
+func (d *Downloader) Resume(taskID string) error {
+	return d.ResumeTask(taskID)
+}
+
+// Delete 实现 Fetcher 接口：优先按活动任务删除，找不到再按已完成任务删除
+func (d *Downloader) Delete(taskID string) error {
+	if _, exists := d.activeTaskExists(taskID); exists {
+		return d.DeleteActiveTask(taskID)
+	}
+	return d.DeleteCompletedTask(taskID)
+}
+
+// activeTaskExists 判断任务是否还在活动任务集合中
+func (d *Downloader) activeTaskExists(taskID string) (*model.Task, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	task, exists := d.activeTasks[taskID]
+	return task, exists
+}
+
+// Status 实现 Fetcher 接口，等价于 GetTask
+func (d *Downloader) Status(taskID string) (*model.Task, bool) {
+	return d.GetTask(taskID)
+}
+
+// ListActive 实现 Fetcher 接口，等价于 GetActiveTasks
+func (d *Downloader) ListActive() []*model.Task {
+	return d.GetActiveTasks()
+}
+
+// ListCompleted 实现 Fetcher 接口，等价于 GetCompletedTasks
+func (d *Downloader) ListCompleted() []*model.Task {
+	return d.GetCompletedTasks()
+}
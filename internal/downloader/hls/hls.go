@@ -0,0 +1,193 @@
+// Package hls 实现HLS（m3u8）直播/点播流的录制：周期性重新拉取媒体播放列表，
+// 下载新出现的分片并写入目标文件；如果系统中装有ffmpeg，优先用它把TS流混成
+// 请求的目标格式（如FLV/MP4），否则直接拼接TS分片
+package hls
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultRefreshInterval 是两次重新拉取播放列表之间的默认间隔
+const DefaultRefreshInterval = 5 * time.Second
+
+// Config 描述录制行为的可调参数
+type Config struct {
+	RefreshInterval time.Duration // 播放列表重新拉取间隔，<=0时使用DefaultRefreshInterval
+}
+
+// Recorder 录制一路HLS流到本地文件
+type Recorder struct {
+	cfg    Config
+	client *http.Client
+}
+
+// New 创建一个新的Recorder
+func New(client *http.Client, cfg Config) *Recorder {
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = DefaultRefreshInterval
+	}
+	return &Recorder{cfg: cfg, client: client}
+}
+
+// Record 拉取playlistURL指向的m3u8播放列表并持续下载新分片，写入outputPath，
+// 直到播放列表出现#EXT-X-ENDLIST或ctx被取消。onProgress在每下载完一个分片后
+// 被调用，汇报当前累计写入的字节数
+func (r *Recorder) Record(ctx context.Context, playlistURL, outputPath string, onProgress func(int64)) error {
+	out, finish, err := openOutput(ctx, outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	seen := make(map[string]struct{})
+	var totalBytes int64
+	ticker := time.NewTicker(r.cfg.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		segments, ended, err := r.fetchPlaylist(playlistURL)
+		if err != nil {
+			return fmt.Errorf("拉取m3u8播放列表失败: %w", err)
+		}
+
+		for _, seg := range segments {
+			if _, done := seen[seg]; done {
+				continue
+			}
+			seen[seg] = struct{}{}
+
+			n, err := r.downloadSegment(ctx, seg, out)
+			if err != nil {
+				return fmt.Errorf("下载分片失败: %w", err)
+			}
+			totalBytes += n
+			if onProgress != nil {
+				onProgress(totalBytes)
+			}
+		}
+
+		if ended {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("关闭输出失败: %w", err)
+	}
+	return finish()
+}
+
+// fetchPlaylist 拉取并解析媒体播放列表，返回按出现顺序排列的分片绝对URL，
+// 以及是否已经遇到#EXT-X-ENDLIST（点播或直播已结束）
+func (r *Recorder) fetchPlaylist(playlistURL string) (segments []string, ended bool, err error) {
+	resp, err := r.client.Get(playlistURL)
+	if err != nil {
+		return nil, false, fmt.Errorf("请求播放列表失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("播放列表返回错误状态码: %d", resp.StatusCode)
+	}
+
+	base, err := url.Parse(playlistURL)
+	if err != nil {
+		return nil, false, fmt.Errorf("解析播放列表地址失败: %w", err)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == "#EXT-X-ENDLIST":
+			ended = true
+		case strings.HasPrefix(line, "#EXT-X-DISCONTINUITY"):
+			// 流媒体时间基不连续（如广告插入）：TS分片仍按顺序拼接写入，
+			// 混流/播放时由ffmpeg或播放器自行处理时间戳跳变
+		case strings.HasPrefix(line, "#"):
+			// 其余标签（如#EXTINF）不携带分片地址，忽略
+		default:
+			segments = append(segments, resolveSegmentURL(base, line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("读取播放列表失败: %w", err)
+	}
+
+	return segments, ended, nil
+}
+
+// resolveSegmentURL 把播放列表中的分片地址（可能是相对路径）解析成绝对URL
+func resolveSegmentURL(base *url.URL, ref string) string {
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(refURL).String()
+}
+
+// downloadSegment 下载单个TS分片并写入out，返回写入的字节数
+func (r *Recorder) downloadSegment(ctx context.Context, segURL string, out io.Writer) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, segURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("创建分片请求失败: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("请求分片失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("分片返回错误状态码: %d", resp.StatusCode)
+	}
+
+	return io.Copy(out, resp.Body)
+}
+
+// openOutput 根据系统是否安装了ffmpeg选择输出方式：装有ffmpeg时通过管道把
+// TS流交给ffmpeg混成outputPath指定的格式；否则直接把TS分片拼接写入outputPath。
+// 返回的finish函数必须在out被关闭之后调用，用于等待ffmpeg退出并检查混流结果
+func openOutput(ctx context.Context, outputPath string) (out io.WriteCloser, finish func() error, err error) {
+	if _, lookErr := exec.LookPath("ffmpeg"); lookErr == nil {
+		cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-f", "mpegts", "-i", "pipe:0", "-c", "copy", outputPath)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, nil, fmt.Errorf("创建ffmpeg输入管道失败: %w", err)
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, fmt.Errorf("启动ffmpeg失败: %w", err)
+		}
+		return stdin, func() error {
+			if err := cmd.Wait(); err != nil {
+				return fmt.Errorf("ffmpeg混流失败: %w", err)
+			}
+			return nil
+		}, nil
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建输出文件失败: %w", err)
+	}
+	return file, func() error { return nil }, nil
+}
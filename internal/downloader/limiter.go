@@ -0,0 +1,104 @@
+package downloader
+
+import (
+	"io"
+
+	"github.com/juju/ratelimit"
+
+	"github.com/luan78zao/live_stream_downloader/internal/model"
+)
+
+// applyRateLimit 如果设置了全局限速，把响应体包装成限速 Reader，所有任务的读取共用同一个令牌桶，
+// 从而让限速在所有任务间统一生效
+func (d *Downloader) applyRateLimit(r io.Reader) io.Reader {
+	d.limitMu.RLock()
+	bucket := d.bucket
+	d.limitMu.RUnlock()
+
+	if bucket == nil {
+		return r
+	}
+	return ratelimit.Reader(r, bucket)
+}
+
+// SetLimits 运行时调整全局限速（字节/秒）和最大并发任务数，两者都传 0 表示不限制
+func (d *Downloader) SetLimits(maxDownloadSpeed int64, maxTasks int) {
+	d.limitMu.Lock()
+	if maxDownloadSpeed > 0 {
+		d.bucket = ratelimit.NewBucketWithRate(float64(maxDownloadSpeed), maxDownloadSpeed)
+	} else {
+		d.bucket = nil
+	}
+	d.maxTasks = maxTasks
+	d.limitMu.Unlock()
+
+	// 放宽并发限制后，尝试唤醒排队中的任务
+	d.dispatchReady()
+}
+
+// GetLimits 返回当前的全局限速和最大并发任务数
+func (d *Downloader) GetLimits() (maxDownloadSpeed int64, maxTasks int) {
+	d.limitMu.RLock()
+	defer d.limitMu.RUnlock()
+
+	if d.bucket != nil {
+		maxDownloadSpeed = int64(d.bucket.Rate())
+	}
+	return maxDownloadSpeed, d.maxTasks
+}
+
+// acquireSlot 尝试占用一个下载并发名额，达到上限时返回 false
+func (d *Downloader) acquireSlot() bool {
+	d.limitMu.Lock()
+	defer d.limitMu.Unlock()
+
+	if d.maxTasks > 0 && d.runningTasks >= d.maxTasks {
+		return false
+	}
+	d.runningTasks++
+	return true
+}
+
+// releaseSlot 释放一个下载并发名额，并尝试从排队队列中取出下一个任务开始下载
+func (d *Downloader) releaseSlot() {
+	d.limitMu.Lock()
+	if d.runningTasks > 0 {
+		d.runningTasks--
+	}
+	d.limitMu.Unlock()
+
+	d.dispatchReady()
+}
+
+// dispatchReady 从等待队列中取出任务开始下载，直到占满并发名额或队列为空
+func (d *Downloader) dispatchReady() {
+	for {
+		d.mu.Lock()
+		if len(d.readyQueue) == 0 {
+			d.mu.Unlock()
+			return
+		}
+		if !d.acquireSlot() {
+			d.mu.Unlock()
+			return
+		}
+
+		task := d.readyQueue[0]
+		d.readyQueue = d.readyQueue[1:]
+		task.Status = model.TaskStatusDownloading
+		d.dispatchTask(task)
+		d.mu.Unlock()
+
+		d.persistTask(task)
+	}
+}
+
+// removeFromReadyQueue 把任务从排队队列中移除；调用方必须持有 d.mu
+func (d *Downloader) removeFromReadyQueue(taskID string) {
+	for i, t := range d.readyQueue {
+		if t.ID == taskID {
+			d.readyQueue = append(d.readyQueue[:i], d.readyQueue[i+1:]...)
+			return
+		}
+	}
+}
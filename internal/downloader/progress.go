@@ -0,0 +1,159 @@
+package downloader
+
+import (
+	"time"
+
+	"github.com/luan78zao/live_stream_downloader/internal/model"
+)
+
+// progressTickInterval 是进度采样和 SSE 推送的频率
+const progressTickInterval = 500 * time.Millisecond
+
+// speedWindow 是计算平滑速度使用的采样窗口大小（tick 数）
+const speedWindow = 10
+
+// Event 表示通过 SSE 推送给客户端的一帧任务进度
+type Event struct {
+	ID         string           `json:"id"`
+	Downloaded int64            `json:"downloaded"`
+	Total      int64            `json:"total"`
+	SpeedBps   float64          `json:"speed_bps"`
+	ETASeconds float64          `json:"eta_seconds"`
+	Status     model.TaskStatus `json:"status"`
+}
+
+// sample 记录某次 tick 时的已下载字节数，用于计算平滑速度
+type sample struct {
+	at    time.Time
+	bytes int64
+}
+
+// ringBuffer 保存最近 speedWindow 次采样，用于平滑速度和 ETA 计算
+type ringBuffer struct {
+	samples []sample
+}
+
+func (r *ringBuffer) add(s sample) {
+	r.samples = append(r.samples, s)
+	if len(r.samples) > speedWindow {
+		r.samples = r.samples[len(r.samples)-speedWindow:]
+	}
+}
+
+// speed 返回窗口内的平均下载速度（字节/秒）
+func (r *ringBuffer) speed() float64 {
+	if len(r.samples) < 2 {
+		return 0
+	}
+	first := r.samples[0]
+	last := r.samples[len(r.samples)-1]
+	elapsed := last.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(last.bytes-first.bytes) / elapsed
+}
+
+// Subscribe 注册一个进度事件订阅者，调用方负责在不再需要时调用 Unsubscribe
+func (d *Downloader) Subscribe() chan Event {
+	ch := make(chan Event, 16)
+
+	d.subsMu.Lock()
+	d.subscribers[ch] = struct{}{}
+	d.subsMu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe 注销一个订阅者并关闭对应的 channel
+func (d *Downloader) Unsubscribe(ch chan Event) {
+	d.subsMu.Lock()
+	defer d.subsMu.Unlock()
+
+	if _, exists := d.subscribers[ch]; exists {
+		delete(d.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish 把事件广播给所有订阅者；订阅者的 channel 已满时丢弃该帧，避免阻塞下载主流程
+func (d *Downloader) publish(evt Event) {
+	d.subsMu.Lock()
+	defer d.subsMu.Unlock()
+
+	for ch := range d.subscribers {
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+}
+
+// taskSnapshot 是runProgressLoop在持有d.mu期间拷贝出来的只读快照，
+// 避免在锁外直接读取task指针上可能被其他goroutine并发写入的字段
+type taskSnapshot struct {
+	id         string
+	downloaded int64
+	total      int64
+	status     model.TaskStatus
+}
+
+// runProgressLoop 周期性地为所有活动任务计算平滑速度/ETA并推送事件，随 Downloader 的生命周期常驻运行
+func (d *Downloader) runProgressLoop() {
+	ticker := time.NewTicker(progressTickInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		d.mu.RLock()
+		tasks := make([]taskSnapshot, 0, len(d.activeTasks))
+		for _, task := range d.activeTasks {
+			downloaded := task.FileSize
+			if task.Resumable {
+				downloaded = task.DownloadedSize
+			}
+			tasks = append(tasks, taskSnapshot{
+				id:         task.ID,
+				downloaded: downloaded,
+				total:      task.TotalSize,
+				status:     task.Status,
+			})
+		}
+		d.mu.RUnlock()
+
+		d.samplesMu.Lock()
+		seen := make(map[string]struct{}, len(tasks))
+		for _, task := range tasks {
+			seen[task.id] = struct{}{}
+
+			rb, exists := d.samples[task.id]
+			if !exists {
+				rb = &ringBuffer{}
+				d.samples[task.id] = rb
+			}
+			rb.add(sample{at: now, bytes: task.downloaded})
+
+			speed := rb.speed()
+			var eta float64
+			if speed > 0 && task.total > task.downloaded {
+				eta = float64(task.total-task.downloaded) / speed
+			}
+
+			d.publish(Event{
+				ID:         task.id,
+				Downloaded: task.downloaded,
+				Total:      task.total,
+				SpeedBps:   speed,
+				ETASeconds: eta,
+				Status:     task.status,
+			})
+		}
+
+		// 清理已经不再活动的任务的采样窗口，避免内存泄漏
+		for id := range d.samples {
+			if _, active := seen[id]; !active {
+				delete(d.samples, id)
+			}
+		}
+		d.samplesMu.Unlock()
+	}
+}
@@ -0,0 +1,112 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/luan78zao/live_stream_downloader/internal/downloader/hls"
+	"github.com/luan78zao/live_stream_downloader/internal/downloader/rtmp"
+	"github.com/luan78zao/live_stream_downloader/internal/model"
+)
+
+// detectProtocol 根据URL的scheme/扩展名判断任务应使用哪种拉流协议；
+// 无法识别时回退到普通HTTP(S)下载
+func detectProtocol(rawURL string) model.Protocol {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return model.ProtocolHTTP
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "rtmp", "rtsp":
+		return model.ProtocolRTMP
+	}
+
+	if strings.Contains(strings.ToLower(u.Path), ".m3u8") {
+		return model.ProtocolHLS
+	}
+
+	return model.ProtocolHTTP
+}
+
+// startHLSDownload 录制一路HLS流；不支持断点续传，进度通过周期性更新task.FileSize体现
+func (d *Downloader) startHLSDownload(task *model.Task) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.mu.Lock()
+	d.cancelFuncs[task.ID] = cancel
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.cancelFuncs, task.ID)
+		d.mu.Unlock()
+	}()
+
+	refresh := d.hlsRefreshDefault
+	if refresh <= 0 {
+		refresh = hls.DefaultRefreshInterval
+	}
+	if task.HLSRefreshSeconds > 0 {
+		refresh = time.Duration(task.HLSRefreshSeconds) * time.Second
+	}
+	recorder := hls.New(&http.Client{Timeout: 30 * time.Second}, hls.Config{RefreshInterval: refresh})
+
+	err := recorder.Record(ctx, task.URL, task.FilePath, d.throttledProgress(task))
+	d.finishProtocolDownload(task, ctx, err)
+}
+
+// startRTMPDownload 拉取一路RTMP/RTSP流并封装成FLV；不支持断点续传
+func (d *Downloader) startRTMPDownload(task *model.Task) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.mu.Lock()
+	d.cancelFuncs[task.ID] = cancel
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.cancelFuncs, task.ID)
+		d.mu.Unlock()
+	}()
+
+	timeout := d.rtmpTimeoutDefault
+	if task.RTMPTimeoutSeconds > 0 {
+		timeout = time.Duration(task.RTMPTimeoutSeconds) * time.Second
+	}
+
+	err := rtmp.Pull(ctx, task.URL, task.FilePath, rtmp.Config{Timeout: timeout}, d.throttledProgress(task))
+	d.finishProtocolDownload(task, ctx, err)
+}
+
+// throttledProgress 返回一个进度回调，按progressTickInterval节流地把累计写入的
+// 字节数写回task.FileSize，供runProgressLoop计算速度/ETA并推送SSE事件
+func (d *Downloader) throttledProgress(task *model.Task) func(int64) {
+	lastUpdate := time.Now()
+	return func(downloaded int64) {
+		if time.Since(lastUpdate) < progressTickInterval {
+			return
+		}
+		lastUpdate = time.Now()
+		d.mu.Lock()
+		task.FileSize = downloaded
+		d.mu.Unlock()
+	}
+}
+
+// finishProtocolDownload 统一处理HLS/RTMP录制结束后的状态流转：ctx被主动取消
+// （停止/删除；这两种协议不支持暂停）时不视为错误，其余情况按正常结束或出错分别处理
+func (d *Downloader) finishProtocolDownload(task *model.Task, ctx context.Context, recordErr error) {
+	if recordErr != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		d.handleDownloadError(task, fmt.Errorf("拉流失败: %w", recordErr))
+		return
+	}
+
+	d.mu.RLock()
+	finalSize := task.FileSize
+	d.mu.RUnlock()
+	d.completeTask(task, finalSize)
+}
@@ -0,0 +1,128 @@
+// Package rtmp 使用纯Go实现的RTMP/RTSP客户端拉取直播流，并将音视频包封装成FLV
+// 写入磁盘，用于替代内置的单连接HTTP拉流（直播源多为RTMP/RTSP协议，不支持Range）
+package rtmp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nareix/joy4/av"
+	"github.com/nareix/joy4/format/flv"
+	"github.com/nareix/joy4/format/rtmp"
+	"github.com/nareix/joy4/format/rtsp"
+)
+
+// Config 描述拉流的可调参数
+type Config struct {
+	Timeout time.Duration // 连接/读取超时时间，<=0时不设置超时
+}
+
+// Pull 连接streamURL指向的RTMP或RTSP流，读取音视频包并封装成FLV写入outputPath。
+// onProgress在每写入一个数据包后被调用，汇报当前累计写入的字节数
+func Pull(ctx context.Context, streamURL, outputPath string, cfg Config, onProgress func(int64)) error {
+	demuxer, resetDeadline, err := dial(streamURL, cfg)
+	if err != nil {
+		return fmt.Errorf("连接直播流失败: %w", err)
+	}
+	defer demuxer.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			demuxer.Close()
+		case <-done:
+		}
+	}()
+
+	streams, err := demuxer.Streams()
+	if err != nil {
+		return fmt.Errorf("读取流信息失败: %w", err)
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("创建输出文件失败: %w", err)
+	}
+	defer file.Close()
+
+	muxer := flv.NewMuxer(file)
+	if err := muxer.WriteHeader(streams); err != nil {
+		return fmt.Errorf("写入FLV头失败: %w", err)
+	}
+
+	var totalBytes int64
+	for {
+		pkt, err := demuxer.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("读取数据包失败: %w", err)
+		}
+		resetDeadline()
+
+		if err := muxer.WritePacket(pkt); err != nil {
+			return fmt.Errorf("写入FLV数据包失败: %w", err)
+		}
+
+		totalBytes += int64(len(pkt.Data))
+		if onProgress != nil {
+			onProgress(totalBytes)
+		}
+	}
+
+	if err := muxer.WriteTrailer(); err != nil {
+		return fmt.Errorf("写入FLV尾部失败: %w", err)
+	}
+
+	return nil
+}
+
+// dial 根据URL的scheme选择RTMP或RTSP客户端并建立连接。返回的resetDeadline需要在每次
+// 成功读取到数据包后调用一次：RTMP连接本身没有空闲超时的概念，只能通过net.Conn的
+// Deadline模拟，如果只在连接建立时设置一次，录制时长一旦超过Timeout就会被提前截断，
+// 所以要在每次读到数据时把Deadline往后滚动，使其实际表现为"空闲超时"而非"总时长超时"；
+// RTSP客户端的RtpTimeout本身就是按包计算的空闲超时，不需要额外处理
+func dial(streamURL string, cfg Config) (demuxer av.DemuxCloser, resetDeadline func(), err error) {
+	u, err := url.Parse(streamURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析流地址失败: %w", err)
+	}
+
+	noop := func() {}
+
+	switch strings.ToLower(u.Scheme) {
+	case "rtmp":
+		conn, err := rtmp.Dial(streamURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cfg.Timeout <= 0 {
+			return conn, noop, nil
+		}
+		netConn := conn.NetConn()
+		netConn.SetDeadline(time.Now().Add(cfg.Timeout))
+		return conn, func() { netConn.SetDeadline(time.Now().Add(cfg.Timeout)) }, nil
+	case "rtsp":
+		client, err := rtsp.Dial(streamURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cfg.Timeout > 0 {
+			client.RtpTimeout = cfg.Timeout
+		}
+		return client, noop, nil
+	default:
+		return nil, nil, fmt.Errorf("不支持的直播协议: %s", u.Scheme)
+	}
+}
@@ -6,22 +6,23 @@ import (
 	"html/template"
 	"net/http"
 	"path/filepath"
-	_ "strconv"
-	"strings" // 添加strings包
-	_ "time"
+	"strconv"
+	"strings"
 
 	"github.com/luan78zao/live_stream_downloader/internal/downloader"
 )
 
 // Handler 处理HTTP请求
 type Handler struct {
-	downloader *downloader.Downloader
-	templates  *template.Template
-	dataDir    string
+	downloader downloader.Fetcher
+	// local 仅在使用内置下载驱动时非空，用于SSE推送、限速等aria2驱动不支持的功能
+	local     *downloader.Downloader
+	templates *template.Template
+	dataDir   string
 }
 
 // New 创建一个新的处理器
-func New(downloader *downloader.Downloader, templatesDir, dataDir string) (*Handler, error) {
+func New(fetcher downloader.Fetcher, templatesDir, dataDir string) (*Handler, error) {
 	// 在New函数中，创建模板函数映射
 	funcMap := template.FuncMap{
 		"contains": strings.Contains,
@@ -44,8 +45,11 @@ func New(downloader *downloader.Downloader, templatesDir, dataDir string) (*Hand
 	templatesPath := filepath.Join("/app", "web", "templates", "*.html")
 	templates := template.Must(template.New("").Funcs(funcMap).ParseGlob(templatesPath))
 
+	local, _ := fetcher.(*downloader.Downloader)
+
 	return &Handler{
-		downloader: downloader,
+		downloader: fetcher,
+		local:      local,
 		templates:  templates,
 		dataDir:    dataDir,
 	}, nil
@@ -72,6 +76,14 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	// 添加删除任务的路由
 	mux.HandleFunc("/api/tasks/delete/active/", h.handleDeleteActiveTask)
 	mux.HandleFunc("/api/tasks/delete/completed/", h.handleDeleteCompletedTask)
+	// 分片下载的暂停/恢复路由
+	mux.HandleFunc("/api/tasks/pause/", h.handlePauseTask)
+	mux.HandleFunc("/api/tasks/resume/", h.handleResumeTask)
+	// 运行时限速/并发设置
+	mux.HandleFunc("/api/settings", h.handleSettings)
+	// 任务进度的SSE推送
+	mux.HandleFunc("/api/tasks/events", h.handleTaskEvents)
+	mux.HandleFunc("/api/tasks/events/", h.handleTaskEventsByID)
 }
 
 // handleIndex 处理首页请求
@@ -86,7 +98,7 @@ func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
 
 // handleActive 处理活动任务页面请求
 func (h *Handler) handleActive(w http.ResponseWriter, r *http.Request) {
-	activeTasks := h.downloader.GetActiveTasks()
+	activeTasks := h.downloader.ListActive()
 	h.templates.ExecuteTemplate(w, "active.html", map[string]interface{}{
 		"Tasks": activeTasks,
 	})
@@ -94,7 +106,7 @@ func (h *Handler) handleActive(w http.ResponseWriter, r *http.Request) {
 
 // handleCompleted 处理已完成任务页面请求
 func (h *Handler) handleCompleted(w http.ResponseWriter, r *http.Request) {
-	completedTasks := h.downloader.GetCompletedTasks()
+	completedTasks := h.downloader.ListCompleted()
 	h.templates.ExecuteTemplate(w, "completed.html", map[string]interface{}{
 		"Tasks": completedTasks,
 	})
@@ -109,11 +121,14 @@ func (h *Handler) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 
 	// 定义请求结构
 	type CreateTaskRequest struct {
-		URL      string `json:"url"`
-		FileName string `json:"file_name"`
+		URL                string `json:"url"`
+		FileName           string `json:"file_name"`
+		HLSRefreshSeconds  int    `json:"hls_refresh_seconds,omitempty"`  // 仅url被识别为m3u8时生效
+		RTMPTimeoutSeconds int    `json:"rtmp_timeout_seconds,omitempty"` // 仅url被识别为rtmp/rtsp时生效
 	}
 
 	var url, fileName string
+	var hlsRefreshSeconds, rtmpTimeoutSeconds int
 
 	// 根据Content-Type处理不同格式的请求
 	contentType := r.Header.Get("Content-Type")
@@ -127,6 +142,8 @@ func (h *Handler) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		}
 		url = req.URL
 		fileName = req.FileName
+		hlsRefreshSeconds = req.HLSRefreshSeconds
+		rtmpTimeoutSeconds = req.RTMPTimeoutSeconds
 	} else {
 		// 解析表单数据（兼容现有的表单提交方式）
 		if err := r.ParseForm(); err != nil {
@@ -135,6 +152,8 @@ func (h *Handler) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		}
 		url = r.FormValue("url")
 		fileName = r.FormValue("file_name")
+		hlsRefreshSeconds, _ = strconv.Atoi(r.FormValue("hls_refresh_seconds"))
+		rtmpTimeoutSeconds, _ = strconv.Atoi(r.FormValue("rtmp_timeout_seconds"))
 	}
 
 	// 验证URL
@@ -144,7 +163,7 @@ func (h *Handler) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 创建任务
-	task, err := h.downloader.CreateTask(url, fileName)
+	task, err := h.downloader.Create(url, fileName, hlsRefreshSeconds, rtmpTimeoutSeconds)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("创建任务失败: %v", err), http.StatusInternalServerError)
 		return
@@ -163,7 +182,7 @@ func (h *Handler) handleGetActiveTasks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取活动任务
-	activeTasks := h.downloader.GetActiveTasks()
+	activeTasks := h.downloader.ListActive()
 
 	// 返回任务信息
 	w.Header().Set("Content-Type", "application/json")
@@ -178,7 +197,7 @@ func (h *Handler) handleGetCompletedTasks(w http.ResponseWriter, r *http.Request
 	}
 
 	// 获取已完成任务
-	completedTasks := h.downloader.GetCompletedTasks()
+	completedTasks := h.downloader.ListCompleted()
 
 	// 返回任务信息
 	w.Header().Set("Content-Type", "application/json")
@@ -200,7 +219,7 @@ func (h *Handler) handleStopTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 停止任务
-	if err := h.downloader.StopTask(taskID); err != nil {
+	if err := h.downloader.Stop(taskID); err != nil {
 		http.Error(w, fmt.Sprintf("停止任务失败: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -210,6 +229,140 @@ func (h *Handler) handleStopTask(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
 }
 
+// handlePauseTask 处理暂停任务请求
+func (h *Handler) handlePauseTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskID := r.URL.Path[len("/api/tasks/pause/"):]
+	if taskID == "" {
+		http.Error(w, "任务ID不能为空", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.downloader.Pause(taskID); err != nil {
+		http.Error(w, fmt.Sprintf("暂停任务失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleResumeTask 处理恢复任务请求
+func (h *Handler) handleResumeTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskID := r.URL.Path[len("/api/tasks/resume/"):]
+	if taskID == "" {
+		http.Error(w, "任务ID不能为空", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.downloader.Resume(taskID); err != nil {
+		http.Error(w, fmt.Sprintf("恢复任务失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// settingsRequest 对应 /api/settings 的请求/响应体
+type settingsRequest struct {
+	MaxDownloadSpeed int64 `json:"max_download_speed"`
+	MaxTasks         int   `json:"max_tasks"`
+}
+
+// handleSettings 处理限速/并发设置的读取与修改；仅本地下载驱动支持
+func (h *Handler) handleSettings(w http.ResponseWriter, r *http.Request) {
+	if h.local == nil {
+		http.Error(w, "当前下载驱动不支持限速/并发设置", http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		maxSpeed, maxTasks := h.local.GetLimits()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(settingsRequest{MaxDownloadSpeed: maxSpeed, MaxTasks: maxTasks})
+	case http.MethodPut:
+		var req settingsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("解析JSON失败: %v", err), http.StatusBadRequest)
+			return
+		}
+		h.local.SetLimits(req.MaxDownloadSpeed, req.MaxTasks)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTaskEvents 处理所有任务的进度SSE推送
+func (h *Handler) handleTaskEvents(w http.ResponseWriter, r *http.Request) {
+	h.streamEvents(w, r, "")
+}
+
+// handleTaskEventsByID 处理单个任务的进度SSE推送
+func (h *Handler) handleTaskEventsByID(w http.ResponseWriter, r *http.Request) {
+	taskID := r.URL.Path[len("/api/tasks/events/"):]
+	if taskID == "" {
+		http.Error(w, "任务ID不能为空", http.StatusBadRequest)
+		return
+	}
+	h.streamEvents(w, r, taskID)
+}
+
+// streamEvents 以SSE格式持续推送任务进度，taskID为空表示推送所有任务；仅本地下载驱动支持
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request, taskID string) {
+	if h.local == nil {
+		http.Error(w, "当前下载驱动不支持进度推送", http.StatusNotImplemented)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "当前连接不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := h.local.Subscribe()
+	defer h.local.Unsubscribe(events)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case evt, ok := <-events:
+			if !ok {
+				return
+			}
+			if taskID != "" && evt.ID != taskID {
+				continue
+			}
+			data, err := json.Marshal(evt)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
 // handleDeleteActiveTask 处理删除活动任务请求
 func (h *Handler) handleDeleteActiveTask(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
@@ -225,7 +378,7 @@ func (h *Handler) handleDeleteActiveTask(w http.ResponseWriter, r *http.Request)
 	}
 
 	// 删除任务
-	if err := h.downloader.DeleteActiveTask(taskID); err != nil {
+	if err := h.downloader.Delete(taskID); err != nil {
 		http.Error(w, fmt.Sprintf("删除任务失败: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -250,7 +403,7 @@ func (h *Handler) handleDeleteCompletedTask(w http.ResponseWriter, r *http.Reque
 	}
 
 	// 删除任务
-	if err := h.downloader.DeleteCompletedTask(taskID); err != nil {
+	if err := h.downloader.Delete(taskID); err != nil {
 		http.Error(w, fmt.Sprintf("删除任务失败: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -11,17 +11,42 @@ const (
 	TaskStatusDownloading TaskStatus = "downloading" // 下载中
 	TaskStatusCompleted   TaskStatus = "completed"   // 已完成
 	TaskStatusError       TaskStatus = "error"       // 出错
+	TaskStatusPaused      TaskStatus = "paused"      // 已暂停
+	TaskStatusReady       TaskStatus = "ready"       // 排队等待下载
+	TaskStatusInterrupted TaskStatus = "interrupted" // 进程重启前仍在下载，中断待恢复
 )
 
-// Task 表示一个下载任务
+// Protocol 标识任务拉流使用的协议，决定了 Downloader 启动哪一套下载逻辑
+type Protocol string
+
+const (
+	ProtocolHTTP Protocol = "http" // 普通HTTP(S)下载，支持时使用分片并行/断点续传
+	ProtocolHLS  Protocol = "hls"  // m3u8播放列表，逐分片拉取后拼接/混流
+	ProtocolRTMP Protocol = "rtmp" // RTMP/RTSP直播流，拉取后封装为FLV
+)
+
+// Task 表示一个下载任务。结构体同时承担 HTTP API 的 JSON 序列化和
+// internal/store 的 SQLite 持久化，因此同时带有 json 和 gorm 标签
 type Task struct {
-	ID           string     `json:"id"`            // 任务ID
-	URL          string     `json:"url"`           // 直播流URL
-	FileName     string     `json:"file_name"`     // 文件名
-	FilePath     string     `json:"file_path"`     // 文件路径
-	Status       TaskStatus `json:"status"`        // 任务状态
-	FileSize     int64      `json:"file_size"`     // 文件大小（字节）
-	StartTime    time.Time  `json:"start_time"`    // 开始时间
-	EndTime      *time.Time `json:"end_time"`     // 结束时间
-	ErrorMessage string     `json:"error_message"` // 错误信息
+	ID             string     `json:"id" gorm:"column:id;primaryKey"`
+	URL            string     `json:"url" gorm:"column:url"`                     // 直播流/下载URL
+	FileName       string     `json:"file_name" gorm:"column:file_name"`         // 文件名
+	FilePath       string     `json:"file_path" gorm:"column:file_path"`         // 文件路径
+	Status         TaskStatus `json:"status" gorm:"column:status;index"`         // 任务状态
+	FileSize       int64      `json:"file_size" gorm:"column:file_size"`         // 文件大小（字节），直播流下载时表示已下载大小
+	TotalSize      int64      `json:"total_size" gorm:"column:total_size"`       // 文件总大小（字节），仅分片下载时已知
+	DownloadedSize int64      `json:"downloaded_size" gorm:"column:downloaded_size"` // 已下载大小（字节），仅分片下载时使用
+	Speed          float64    `json:"speed" gorm:"column:speed"`                 // 最近一次采样的下载速度（字节/秒）
+	Resumable      bool       `json:"resumable" gorm:"column:resumable"`         // 服务器是否支持断点续传（分片下载）
+	Parent         string     `json:"parent,omitempty" gorm:"column:parent;index"` // 所属的父任务ID（如多文件种子的子文件）
+	StartTime      time.Time  `json:"start_time" gorm:"column:start_time"`       // 开始时间
+	EndTime        *time.Time `json:"end_time" gorm:"column:end_time"`           // 结束时间
+	ErrorMessage   string     `json:"error_message" gorm:"column:error_message"` // 错误信息
+	CreatedAt      time.Time  `json:"created_at" gorm:"column:created_at;autoCreateTime"` // 记录创建时间
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"column:updated_at;autoUpdateTime"` // 记录最后更新时间
+	Files          []string   `json:"files,omitempty" gorm:"-"` // 多文件任务（如种子）包含的文件路径列表，仅部分驱动使用，不落库
+
+	Protocol           Protocol `json:"protocol,omitempty" gorm:"column:protocol"`                          // 拉流协议，默认为ProtocolHTTP
+	HLSRefreshSeconds  int      `json:"hls_refresh_seconds,omitempty" gorm:"column:hls_refresh_seconds"`    // HLS播放列表重新拉取间隔（秒），<=0使用默认值，仅Protocol为hls时生效
+	RTMPTimeoutSeconds int      `json:"rtmp_timeout_seconds,omitempty" gorm:"column:rtmp_timeout_seconds"` // RTMP/RTSP连接超时（秒），<=0使用默认值，仅Protocol为rtmp时生效
 }
\ No newline at end of file
@@ -0,0 +1,87 @@
+// Package store 提供基于 SQLite 的任务持久化，使下载任务能够在进程重启后恢复
+package store
+
+import (
+	"fmt"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/luan78zao/live_stream_downloader/internal/model"
+)
+
+// Store 封装对任务表的增删查操作
+type Store struct {
+	db *gorm.DB
+}
+
+// New 打开（或创建）SQLite数据库文件并自动迁移表结构
+func New(dbPath string) (*Store, error) {
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+
+	if err := db.AutoMigrate(&model.Task{}); err != nil {
+		return nil, fmt.Errorf("迁移数据库表结构失败: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// SaveTask 新增或更新一条任务记录
+func (s *Store) SaveTask(task *model.Task) error {
+	if err := s.db.Save(task).Error; err != nil {
+		return fmt.Errorf("保存任务记录失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteTask 删除一条任务记录
+func (s *Store) DeleteTask(id string) error {
+	if err := s.db.Delete(&model.Task{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("删除任务记录失败: %w", err)
+	}
+	return nil
+}
+
+// GetTaskByID 按ID查询任务
+func (s *Store) GetTaskByID(id string) (*model.Task, error) {
+	var task model.Task
+	if err := s.db.First(&task, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("查询任务失败: %w", err)
+	}
+	return &task, nil
+}
+
+// GetTasksByStatus 查询处于指定状态之一的所有任务
+func (s *Store) GetTasksByStatus(status ...model.TaskStatus) ([]*model.Task, error) {
+	var tasks []*model.Task
+	if err := s.db.Where("status in ?", status).Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("按状态查询任务失败: %w", err)
+	}
+	return tasks, nil
+}
+
+// GetTasksPaged 按创建时间倒序分页查询任务，page从1开始，返回当前页任务和总数
+func (s *Store) GetTasksPaged(page, size int) ([]*model.Task, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 20
+	}
+
+	var total int64
+	if err := s.db.Model(&model.Task{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计任务总数失败: %w", err)
+	}
+
+	var tasks []*model.Task
+	offset := (page - 1) * size
+	if err := s.db.Order("created_at desc").Offset(offset).Limit(size).Find(&tasks).Error; err != nil {
+		return nil, 0, fmt.Errorf("分页查询任务失败: %w", err)
+	}
+
+	return tasks, total, nil
+}